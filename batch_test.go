@@ -0,0 +1,129 @@
+package main
+
+import "testing"
+
+// resetBatchTestState gives each test a clean store and batch/queue
+// bookkeeping, since completeBatchLocked touches the package-level globals.
+func resetBatchTestState() {
+	store = NewMemoryStore()
+	batches = make(map[string]*Batch)
+	queues = make(map[string]*Queue)
+}
+
+func hasPayload(t *testing.T, payload string) bool {
+	t.Helper()
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf("store.List: %v", err)
+	}
+	for _, job := range list {
+		if job.Payload == payload {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompleteBatchLockedFiresSuccessWhenNoneFailed(t *testing.T) {
+	resetBatchTestState()
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	b := &Batch{ID: "b1", Success: &CallbackJob{Payload: "success-payload"}}
+	completeBatchLocked(b)
+
+	if !hasPayload(t, "success-payload") {
+		t.Errorf("completeBatchLocked with Failed=0 should enqueue the success callback")
+	}
+}
+
+func TestCompleteBatchLockedSkipsSuccessWhenAnyFailed(t *testing.T) {
+	resetBatchTestState()
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	b := &Batch{ID: "b2", Success: &CallbackJob{Payload: "should-not-enqueue"}, Failed: 1}
+	completeBatchLocked(b)
+
+	if hasPayload(t, "should-not-enqueue") {
+		t.Errorf("completeBatchLocked should not enqueue the success callback when Failed > 0")
+	}
+}
+
+func TestCompleteBatchLockedCompleteFiresRegardlessOfOutcome(t *testing.T) {
+	resetBatchTestState()
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	b := &Batch{ID: "b3", Complete: &CallbackJob{Payload: "complete-payload"}, Failed: 1}
+	completeBatchLocked(b)
+
+	if !hasPayload(t, "complete-payload") {
+		t.Errorf("completeBatchLocked should always enqueue the complete callback")
+	}
+}
+
+func TestCompleteBatchLockedPropagatesToParent(t *testing.T) {
+	resetBatchTestState()
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	parent := &Batch{ID: "parent", Pending: 1}
+	batches["parent"] = parent
+	child := &Batch{ID: "child", ParentBatchID: "parent"}
+	batches["child"] = child
+
+	completeBatchLocked(child)
+
+	if parent.Pending != 0 {
+		t.Errorf("parent.Pending = %d, want 0 after its only child batch completed", parent.Pending)
+	}
+	if parent.Succeeded != 1 {
+		t.Errorf("parent.Succeeded = %d, want 1 after child batch succeeded", parent.Succeeded)
+	}
+	if parent.Failed != 0 {
+		t.Errorf("parent.Failed = %d, want 0 after child batch succeeded", parent.Failed)
+	}
+}
+
+func TestCompleteBatchLockedPropagatesFailureToParent(t *testing.T) {
+	resetBatchTestState()
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	parent := &Batch{ID: "parent", Pending: 1}
+	batches["parent"] = parent
+	child := &Batch{ID: "child", ParentBatchID: "parent", Failed: 1}
+	batches["child"] = child
+
+	completeBatchLocked(child)
+
+	if parent.Failed != 1 {
+		t.Errorf("parent.Failed = %d, want 1 after child batch had a failure", parent.Failed)
+	}
+	if parent.Succeeded != 0 {
+		t.Errorf("parent.Succeeded = %d, want 0 after child batch had a failure", parent.Succeeded)
+	}
+}
+
+func TestCompleteBatchLockedCascadesWhenParentAlsoCompletes(t *testing.T) {
+	resetBatchTestState()
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	grandparent := &Batch{ID: "gp", Pending: 1, Success: &CallbackJob{Payload: "gp-success"}}
+	batches["gp"] = grandparent
+	parent := &Batch{ID: "parent", ParentBatchID: "gp", Pending: 1}
+	batches["parent"] = parent
+	child := &Batch{ID: "child", ParentBatchID: "parent"}
+	batches["child"] = child
+
+	completeBatchLocked(child)
+
+	if grandparent.Pending != 0 {
+		t.Errorf("grandparent.Pending = %d, want 0 once its only child batch also completed", grandparent.Pending)
+	}
+	if !hasPayload(t, "gp-success") {
+		t.Errorf("grandparent's success callback should fire once the cascade reaches it")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronFieldMatches(t *testing.T) {
+	cases := []struct {
+		field string
+		value int
+		min   int
+		max   int
+		want  bool
+	}{
+		{"*", 17, 0, 59, true},
+		{"5", 5, 0, 59, true},
+		{"5", 6, 0, 59, false},
+		{"1,15,30", 15, 0, 59, true},
+		{"1,15,30", 16, 0, 59, false},
+		{"10-20", 15, 0, 59, true},
+		{"10-20", 21, 0, 59, false},
+		{"*/15", 30, 0, 59, true},
+		{"*/15", 31, 0, 59, false},
+		{"10-20/5", 15, 0, 59, true},
+		{"10-20/5", 16, 0, 59, false},
+	}
+	for _, c := range cases {
+		if got := cronFieldMatches(c.field, c.value, c.min, c.max); got != c.want {
+			t.Errorf("cronFieldMatches(%q, %d, %d, %d) = %v, want %v", c.field, c.value, c.min, c.max, got, c.want)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// "0 9 * * 1-5" = 9:00am on weekdays.
+	cron := "0 9 * * 1-5"
+
+	monday9am := time.Date(2026, time.July, 27, 9, 0, 0, 0, time.UTC)
+	if !cronMatches(cron, monday9am) {
+		t.Errorf("cronMatches(%q, %v) = false, want true (Monday 9am)", cron, monday9am)
+	}
+
+	monday910am := time.Date(2026, time.July, 27, 9, 1, 0, 0, time.UTC)
+	if cronMatches(cron, monday910am) {
+		t.Errorf("cronMatches(%q, %v) = true, want false (9:01am)", cron, monday910am)
+	}
+
+	saturday9am := time.Date(2026, time.August, 1, 9, 0, 0, 0, time.UTC)
+	if cronMatches(cron, saturday9am) {
+		t.Errorf("cronMatches(%q, %v) = true, want false (Saturday)", cron, saturday9am)
+	}
+}
+
+func TestCronMatchesRejectsMalformedExpression(t *testing.T) {
+	if cronMatches("* * *", time.Now()) {
+		t.Errorf("cronMatches with a 3-field expression should be false, not match everything")
+	}
+}
+
+func TestNextCronFireAt(t *testing.T) {
+	after := time.Date(2026, time.July, 27, 9, 30, 0, 0, time.UTC) // Monday 9:30am
+	next, ok := nextCronFireAt("0 9 * * 1-5", after)
+	if !ok {
+		t.Fatalf("nextCronFireAt returned ok=false, want a match")
+	}
+
+	want := time.Date(2026, time.July, 28, 9, 0, 0, 0, time.UTC) // Tuesday 9:00am
+	if got := time.Unix(next, 0).UTC(); !got.Equal(want) {
+		t.Errorf("nextCronFireAt(%q, %v) = %v, want %v", "0 9 * * 1-5", after, got, want)
+	}
+}
+
+func TestNextCronFireAtInvalidCron(t *testing.T) {
+	if _, ok := nextCronFireAt("not a cron", time.Now()); ok {
+		t.Errorf("nextCronFireAt with an invalid expression should report ok=false")
+	}
+}
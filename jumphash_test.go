@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJumpIsDeterministic(t *testing.T) {
+	key := fnv64("partition-key")
+	want := jump(key, 10)
+	for i := 0; i < 100; i++ {
+		if got := jump(key, 10); got != want {
+			t.Fatalf("jump(%d, 10) = %d on call %d, want %d (not deterministic)", key, got, i, want)
+		}
+	}
+}
+
+func TestJumpStaysInRange(t *testing.T) {
+	for _, buckets := range []int{1, 2, 5, 16, 100} {
+		for _, key := range []uint64{0, 1, 42, 1 << 40, ^uint64(0)} {
+			b := jump(key, buckets)
+			if b < 0 || int(b) >= buckets {
+				t.Fatalf("jump(%d, %d) = %d, want in [0, %d)", key, buckets, b, buckets)
+			}
+		}
+	}
+}
+
+func TestJumpSingleBucketAlwaysZero(t *testing.T) {
+	for _, key := range []uint64{0, 7, 12345, ^uint64(0)} {
+		if got := jump(key, 1); got != 0 {
+			t.Fatalf("jump(%d, 1) = %d, want 0", key, got)
+		}
+	}
+}
+
+func TestWorkerForPartitionStable(t *testing.T) {
+	idx := workerForPartition("order-42", 4)
+	if idx < 0 || int(idx) >= 4 {
+		t.Fatalf("workerForPartition returned out-of-range index %d for workerCount=4", idx)
+	}
+	if again := workerForPartition("order-42", 4); again != idx {
+		t.Fatalf("workerForPartition(\"order-42\", 4) = %d then %d, want stable result", idx, again)
+	}
+}
+
+func TestWorkerForPartitionSpreadsKeys(t *testing.T) {
+	seen := make(map[int32]bool)
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("partition-%d", i)
+		seen[workerForPartition(key, 8)] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("workerForPartition mapped 200 distinct keys onto only %d of 8 buckets", len(seen))
+	}
+}
@@ -1,28 +1,177 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
+
+	// Drivers for --store=sql, selected at runtime by newStore based on the
+	// DSN scheme. Blank-imported so database/sql can find them by name.
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
+const defaultQueueName = "default"
+
 var (
-	jobs   = make(map[string]*Job)
-	jobsMu sync.Mutex
+	batches   = make(map[string]*Batch)
+	queues    = make(map[string]*Queue)
+	jobTypes  = make(map[string]*JobType)
+	periodics = make(map[string]*PeriodicJob)
+	workers   = make(map[string]*Worker)
+
+	// metaMu guards everything above: batch/queue/job-type/periodic/worker
+	// bookkeeping that stays in-process regardless of which Store backs job
+	// state. Job state itself lives behind store and has its own locking.
+	metaMu sync.Mutex
+
+	// store is the persistence backend for job state, selected in main()
+	// via --store / DATABASE_URL.
+	store Store
+
+	// shuttingDown is flipped on SIGINT/SIGTERM; /enqueue and /poll reject
+	// while it's set so in-flight leases can drain, analogous to Flynn's
+	// shutdown.IsActive().
+	shuttingDown atomic.Bool
 )
 
 type PollRequest struct {
-	WorkerID string `json:"worker_id"`
+	WorkerID string   `json:"worker_id"`
+	Queues   []string `json:"queues,omitempty"`
+
+	// WorkerIndex/WorkerCount identify this poller's position in the live
+	// worker set, used to route partition_key jobs via jump hash.
+	WorkerIndex int `json:"worker_index,omitempty"`
+	WorkerCount int `json:"worker_count,omitempty"`
 }
 
 type EnqueueRequest struct {
 	Payload string `json:"payload"`
+	BatchID string `json:"batch_id,omitempty"`
+	JobType string `json:"job_type,omitempty"`
+	Queue   string `json:"queue,omitempty"`
+
+	// PartitionKey, if set, sticks the job to whichever worker jump-hashes
+	// to for the current worker count.
+	PartitionKey string `json:"partition_key,omitempty"`
+}
+
+// Worker is a registered poller in the jump-hash ring. Index is stable for
+// the lifetime of the process and determines which partition keys route to it.
+type Worker struct {
+	ID              string `json:"id"`
+	Index           int    `json:"index"`
+	RegisteredAt    int64  `json:"registered_at"`
+	LastHeartbeatAt int64  `json:"last_heartbeat_at,omitempty"`
+
+	// Capacity, as last reported via POST /workers/heartbeat. Used by
+	// GET /status so operators and autoscalers can see load without
+	// querying each worker directly.
+	MaxJobs     int     `json:"max_jobs,omitempty"`
+	CurrentJobs int     `json:"current_jobs,omitempty"`
+	CPUPercent  float64 `json:"cpu_pct,omitempty"`
+	MemPercent  float64 `json:"mem_pct,omitempty"`
+}
+
+// workerHeartbeatTTL is how long a worker can go without a heartbeat before
+// it's pruned from the live set; pruning re-routes any partition-keyed
+// leases it was holding, same as a membership change from /workers/register.
+const workerHeartbeatTTL = 30 * time.Second
+
+// StatusResponse is the payload for GET /status: aggregate job counts,
+// backlog age, DLQ size, and the live worker set with reported capacity.
+// Modeled on the status endpoint in Ignite's encode-server.
+type StatusResponse struct {
+	JobCounts              map[JobState]int `json:"job_counts"`
+	DeadLetterCount        int              `json:"dead_letter_count"`
+	QueueDepth             int              `json:"queue_depth"`
+	OldestQueuedAgeSeconds int64            `json:"oldest_queued_age_seconds,omitempty"`
+	Workers                []*Worker        `json:"workers"`
+}
+
+// Queue holds scheduling metadata for one named queue: its polling priority,
+// consulted when /poll ranks which queue to pull from next. The ready
+// backlog itself lives inside Store now.
+type Queue struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+}
+
+// JobType registers default scheduling parameters for jobs enqueued with a
+// matching job_type, along the lines of Rickover's /v2/job-types.
+type JobType struct {
+	Name         string `json:"name"`
+	MaxTries     int    `json:"max_tries"`
+	LeaseSeconds int64  `json:"lease_seconds"`
+	Concurrency  int    `json:"concurrency,omitempty"`
+	Queue        string `json:"queue"`
+}
+
+// QueueRequest creates or updates a named queue's polling priority via
+// POST /queues.
+type QueueRequest struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+type PeriodicRequest struct {
+	Cron     string `json:"cron"`
+	Payload  string `json:"payload"`
+	JobType  string `json:"job_type,omitempty"`
+	MaxTries int    `json:"max_tries,omitempty"`
+}
+
+// PeriodicJob is a cron-scheduled job definition. The background ticker
+// materializes a real Job from it each time NextFireAt elapses.
+type PeriodicJob struct {
+	ID         string `json:"id"`
+	Cron       string `json:"cron"`
+	Payload    string `json:"payload"`
+	JobType    string `json:"job_type,omitempty"`
+	MaxTries   int    `json:"max_tries,omitempty"`
+	NextFireAt int64  `json:"next_fire_at"`
+	Paused     bool   `json:"paused"`
+}
+
+// CallbackJob describes a job to enqueue automatically when a batch
+// reaches a terminal state.
+type CallbackJob struct {
+	Payload string `json:"payload"`
+}
+
+type BatchRequest struct {
+	Success       *CallbackJob `json:"success,omitempty"`
+	Complete      *CallbackJob `json:"complete,omitempty"`
+	ParentBatchID string       `json:"parent_batch_id,omitempty"`
+}
+
+// Batch tracks the membership of a group of jobs so a success and/or
+// complete callback can be enqueued once all member jobs finish.
+type Batch struct {
+	ID            string       `json:"id"`
+	ParentBatchID string       `json:"parent_batch_id,omitempty"`
+	Success       *CallbackJob `json:"success,omitempty"`
+	Complete      *CallbackJob `json:"complete,omitempty"`
+	Pending       int          `json:"pending"`
+	Succeeded     int          `json:"succeeded"`
+	Failed        int          `json:"failed"`
 }
 
 type AckRequest struct {
@@ -57,25 +206,437 @@ type Job struct {
 	Attempts        int   `json:"attempts"`
 	MaxTries        int   `json:"max_tries"`
 	NextAvailableAt int64 `json:"next_available_at,omitempty"`
+
+	// BatchID, if set, ties this job to a Batch's pending/succeeded/failed counters.
+	BatchID string `json:"batch_id,omitempty"`
+
+	// JobType and Queue record how this job was routed; both are optional.
+	JobType string `json:"job_type,omitempty"`
+	Queue   string `json:"queue,omitempty"`
+
+	// PartitionKey, if set, restricts leasing to the worker it jump-hashes to.
+	PartitionKey string `json:"partition_key,omitempty"`
+
+	// EnqueuedAt records when this job was first created, used by /status
+	// and /poll's x-queue-oldest-age-seconds header to report backlog age.
+	EnqueuedAt int64 `json:"enqueued_at,omitempty"`
+}
+
+// getOrCreateQueueLocked returns the named queue, creating it with the
+// default priority if it doesn't exist yet. Callers must hold metaMu.
+func getOrCreateQueueLocked(name string) *Queue {
+	q, ok := queues[name]
+	if !ok {
+		q = &Queue{Name: name, Priority: 0}
+		queues[name] = q
+	}
+	return q
+}
+
+// buildJob constructs a new Job, routing it to jobType's queue (or
+// queueName, or the default queue). idHint pins the job ID (used by the
+// periodic scheduler so executions can be traced back to their
+// PeriodicJob); pass "" to generate one. It does not touch store or
+// metaMu-guarded state beyond reading jobTypes, so callers must already
+// hold metaMu.
+func buildJob(idHint, payload, batchID, jobType, queueName, partitionKey string) *Job {
+	id := idHint
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	maxTries := 3
+	if jobType != "" {
+		if jt, ok := jobTypes[jobType]; ok {
+			maxTries = jt.MaxTries
+			if queueName == "" {
+				queueName = jt.Queue
+			}
+		}
+	}
+	if queueName == "" {
+		queueName = defaultQueueName
+	}
+
+	return &Job{
+		ID:           id,
+		Payload:      payload,
+		State:        StateQueued,
+		MaxTries:     maxTries,
+		BatchID:      batchID,
+		JobType:      jobType,
+		Queue:        queueName,
+		PartitionKey: partitionKey,
+		EnqueuedAt:   time.Now().Unix(),
+	}
+}
+
+// enqueueLocked builds a job, persists it via store, and registers it
+// against batchID when non-empty. Callers must hold metaMu.
+func enqueueLocked(idHint, payload, batchID, jobType, queueName, partitionKey string) (*Job, error) {
+	job := buildJob(idHint, payload, batchID, jobType, queueName, partitionKey)
+	getOrCreateQueueLocked(job.Queue)
+
+	if err := store.Enqueue(job); err != nil {
+		return nil, err
+	}
+
+	if batchID != "" {
+		if b, ok := batches[batchID]; ok {
+			b.Pending++
+			saveBatchLocked(b)
+		}
+	}
+
+	return job, nil
+}
+
+// leaseSecondsForJob returns the lease duration to grant a job, honoring its
+// job type's configured lease_seconds when present. Callers must hold metaMu.
+func leaseSecondsForJob(job *Job) int64 {
+	if job.JobType != "" {
+		if jt, ok := jobTypes[job.JobType]; ok && jt.LeaseSeconds > 0 {
+			return jt.LeaseSeconds
+		}
+	}
+	return 30
+}
+
+// jump implements Google's Jump Consistent Hash.
+func jump(key uint64, buckets int) int32 {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int32(b)
+}
+
+// fnv64 hashes s with FNV-1a, the input jump expects.
+func fnv64(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// workerForPartition returns which worker index a partition key routes to
+// for the given worker count.
+func workerForPartition(partitionKey string, workerCount int) int32 {
+	return jump(fnv64(partitionKey), workerCount)
+}
+
+// reassignWorkerIndexesLocked recomputes a dense 0..len(workers)-1 index for
+// every live worker, ordered by registration time (ties broken by ID so the
+// order is deterministic). Called on every membership change so a pruned
+// worker's index can't be left permanently unassigned while a later
+// registration collides with a still-live one. Callers must hold metaMu.
+func reassignWorkerIndexesLocked() {
+	ids := make([]string, 0, len(workers))
+	for id := range workers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		wi, wj := workers[ids[i]], workers[ids[j]]
+		if wi.RegisteredAt != wj.RegisteredAt {
+			return wi.RegisteredAt < wj.RegisteredAt
+		}
+		return wi.ID < wj.ID
+	})
+	for i, id := range ids {
+		workers[id].Index = i
+	}
+}
+
+// workerIndexRegisteredLocked reports whether idx belongs to a currently
+// registered worker. Callers must hold metaMu.
+func workerIndexRegisteredLocked(idx int) bool {
+	for _, w := range workers {
+		if w.Index == idx {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneStaleWorkersLocked drops any worker whose last heartbeat (or, if it
+// never sent one, registration) is older than workerHeartbeatTTL, then
+// re-routes any partition-keyed leases since membership just changed.
+// Callers must hold metaMu.
+func pruneStaleWorkersLocked(now int64) {
+	staleBefore := now - int64(workerHeartbeatTTL.Seconds())
+	pruned := false
+	for id, w := range workers {
+		lastSeen := w.RegisteredAt
+		if w.LastHeartbeatAt > lastSeen {
+			lastSeen = w.LastHeartbeatAt
+		}
+		if lastSeen <= staleBefore {
+			delete(workers, id)
+			pruned = true
+		}
+	}
+	if pruned {
+		reassignWorkerIndexesLocked()
+		expirePartitionedLeasesLocked()
+	}
+}
+
+// pruneStaleWorkers is pruneStaleWorkersLocked for callers, like the
+// background ticker, that don't already hold metaMu.
+func pruneStaleWorkers(now int64) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	pruneStaleWorkersLocked(now)
+}
+
+// queueDepthAndOldestAge reports how many jobs are QUEUED and, if any are,
+// how many seconds the oldest of them has been waiting. Used by /status and
+// /poll's x-queue-depth / x-queue-oldest-age-seconds headers.
+func queueDepthAndOldestAge(now int64) (depth int, oldestAgeSeconds int64, err error) {
+	list, err := store.List()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var oldestEnqueuedAt int64
+	for _, job := range list {
+		if job.State != StateQueued {
+			continue
+		}
+		depth++
+		if oldestEnqueuedAt == 0 || job.EnqueuedAt < oldestEnqueuedAt {
+			oldestEnqueuedAt = job.EnqueuedAt
+		}
+	}
+	if oldestEnqueuedAt > 0 {
+		oldestAgeSeconds = now - oldestEnqueuedAt
+	}
+	return depth, oldestAgeSeconds, nil
+}
+
+// expirePartitionedLeasesLocked requeues every leased, partition-keyed job so
+// it gets re-routed on the next poll after worker membership changes.
+// Callers must hold metaMu.
+func expirePartitionedLeasesLocked() {
+	list, err := store.List()
+	if err != nil {
+		log.Println("expirePartitionedLeasesLocked: list:", err)
+		return
+	}
+
+	for _, job := range list {
+		if job.State != StateLeased || job.PartitionKey == "" {
+			continue
+		}
+		if _, err := store.Requeue(job.ID); err != nil {
+			log.Println("expirePartitionedLeasesLocked: requeue:", job.ID, err)
+		}
+	}
+}
+
+// cronFieldMatches reports whether value satisfies a single standard cron
+// field: "*", comma-separated lists, "a-b" ranges, and "*/n" or "a-b/n" steps.
+func cronFieldMatches(field string, value, min, max int) bool {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		rangePart := part
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			rangePart = part[:idx]
+			if n, err := strconv.Atoi(part[idx+1:]); err == nil && n > 0 {
+				step = n
+			}
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			if dash := strings.Index(rangePart, "-"); dash >= 0 {
+				lo, _ = strconv.Atoi(rangePart[:dash])
+				hi, _ = strconv.Atoi(rangePart[dash+1:])
+			} else {
+				n, err := strconv.Atoi(rangePart)
+				if err != nil {
+					continue
+				}
+				lo, hi = n, n
+			}
+		}
+
+		if value < lo || value > hi {
+			continue
+		}
+		if (value-lo)%step == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cronMatches evaluates a standard 5-field "min hour dom month dow" cron
+// expression against t.
+func cronMatches(cron string, t time.Time) bool {
+	fields := strings.Fields(cron)
+	if len(fields) != 5 {
+		return false
+	}
+	return cronFieldMatches(fields[0], t.Minute(), 0, 59) &&
+		cronFieldMatches(fields[1], t.Hour(), 0, 23) &&
+		cronFieldMatches(fields[2], t.Day(), 1, 31) &&
+		cronFieldMatches(fields[3], int(t.Month()), 1, 12) &&
+		cronFieldMatches(fields[4], int(t.Weekday()), 0, 6)
+}
+
+// nextCronFireAt scans minute-by-minute for the next time after `after` that
+// matches cron, so schedules are always derived from wall-clock time rather
+// than an in-memory tick count.
+func nextCronFireAt(cron string, after time.Time) (int64, bool) {
+	if len(strings.Fields(cron)) != 5 {
+		return 0, false
+	}
+
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(4, 0, 0)
+	for t.Before(limit) {
+		if cronMatches(cron, t) {
+			return t.Unix(), true
+		}
+		t = t.Add(time.Minute)
+	}
+	return 0, false
+}
+
+// tickPeriodics materializes a Job for every PeriodicJob whose NextFireAt has
+// elapsed, then recomputes NextFireAt from the current wall clock.
+func tickPeriodics(now int64) {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+
+	for _, p := range periodics {
+		if p.Paused {
+			continue
+		}
+		for p.NextFireAt != 0 && p.NextFireAt <= now {
+			id := fmt.Sprintf("%s:%d", p.ID, p.NextFireAt)
+			job := buildJob(id, p.Payload, "", p.JobType, "", "")
+			if p.MaxTries > 0 {
+				job.MaxTries = p.MaxTries
+			}
+			getOrCreateQueueLocked(job.Queue)
+			if err := store.Enqueue(job); err != nil {
+				log.Println("tickPeriodics: enqueue:", err)
+			}
+
+			next, ok := nextCronFireAt(p.Cron, time.Unix(p.NextFireAt, 0))
+			if !ok {
+				p.NextFireAt = 0
+			} else {
+				p.NextFireAt = next
+			}
+			if err := store.SavePeriodic(p); err != nil {
+				log.Println("tickPeriodics: save:", p.ID, err)
+			}
+			if !ok {
+				break
+			}
+		}
+	}
+}
+
+// saveBatchLocked persists b's current counters and callback payloads, the
+// same way SavePeriodic keeps periodic job definitions crash-safe. Callers
+// must hold metaMu.
+func saveBatchLocked(b *Batch) {
+	if err := store.SaveBatch(b); err != nil {
+		log.Println("saveBatchLocked:", b.ID, err)
+	}
+}
+
+// completeBatchLocked fires a batch's callbacks once its pending count hits
+// zero and, for nested batches, propagates completion up to the parent.
+// Callers must hold metaMu.
+func completeBatchLocked(b *Batch) {
+	if b.Complete != nil {
+		if _, err := enqueueLocked("", b.Complete.Payload, "", "", "", ""); err != nil {
+			log.Println("completeBatchLocked: complete callback:", err)
+		}
+	}
+	if b.Failed == 0 && b.Success != nil {
+		if _, err := enqueueLocked("", b.Success.Payload, "", "", "", ""); err != nil {
+			log.Println("completeBatchLocked: success callback:", err)
+		}
+	}
+
+	if b.ParentBatchID == "" {
+		return
+	}
+	parent, ok := batches[b.ParentBatchID]
+	if !ok {
+		return
+	}
+	parent.Pending--
+	if b.Failed > 0 {
+		parent.Failed++
+	} else {
+		parent.Succeeded++
+	}
+	saveBatchLocked(parent)
+	if parent.Pending == 0 {
+		completeBatchLocked(parent)
+	}
 }
 
 func expireLeases(now int64) []string {
-	var expiredIDs []string = make([]string, 0)
+	expired, err := store.ExpireLeases(now)
+	if err != nil {
+		log.Println("expireLeases:", err)
+		return nil
+	}
 
-	jobsMu.Lock()
-	defer jobsMu.Unlock()
+	ids := make([]string, 0, len(expired))
+	for _, job := range expired {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
 
-	for id, job := range jobs {
-		if job.State == StateLeased && job.LeaseExpiresAt > 0 && job.LeaseExpiresAt <= now {
-			job.State = StateQueued
-			job.LeaseOwner = ""
-			job.LeaseExpiresAt = 0
-			job.NextAvailableAt = 0
-			expiredIDs = append(expiredIDs, id)
+// hasLeasedJobs reports whether any job is still StateLeased, used while
+// draining to decide whether the shutdown grace period can end early.
+func hasLeasedJobs() bool {
+	list, err := store.List()
+	if err != nil {
+		log.Println("hasLeasedJobs:", err)
+		return false
+	}
+	for _, job := range list {
+		if job.State == StateLeased {
+			return true
 		}
 	}
+	return false
+}
+
+// requeueOrphanedLeases forces every still-leased job back to StateQueued.
+// Called once the shutdown grace period elapses so no job is left stranded
+// until its lease would otherwise expire.
+func requeueOrphanedLeases() []string {
+	list, err := store.List()
+	if err != nil {
+		log.Println("requeueOrphanedLeases: list:", err)
+		return nil
+	}
 
-	return expiredIDs
+	var ids []string
+	for _, job := range list {
+		if job.State != StateLeased {
+			continue
+		}
+		if _, err := store.Requeue(job.ID); err != nil {
+			log.Println("requeueOrphanedLeases: requeue:", job.ID, err)
+			continue
+		}
+		ids = append(ids, job.ID)
+	}
+	return ids
 }
 
 // exponential back off and jitter
@@ -95,12 +656,116 @@ func retryDelaySeconds(attempts int) int64 {
 	return rand.Int63n(delay + 1)
 }
 
+// newStore builds the configured Store. DATABASE_URL, if set, takes
+// precedence over storeKind and selects the SQL store with the dialect
+// inferred from its scheme; otherwise storeKind picks between "memory"
+// (the default) and "sql" (which then requires databaseURL/DATABASE_URL).
+func newStore(storeKind, databaseURL string) (Store, error) {
+	if databaseURL == "" {
+		databaseURL = os.Getenv("DATABASE_URL")
+	}
+	if databaseURL != "" {
+		storeKind = "sql"
+	}
+
+	switch storeKind {
+	case "", "memory":
+		return NewMemoryStore(), nil
+
+	case "sql":
+		if databaseURL == "" {
+			return nil, fmt.Errorf("newStore: --store=sql requires --database-url or DATABASE_URL")
+		}
+		dialect := dialectFromDSN(databaseURL)
+		driverName := dialect
+		if dialect == "sqlite" {
+			driverName = "sqlite3"
+		}
+		db, err := sql.Open(driverName, databaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("newStore: open: %w", err)
+		}
+		return NewSQLStore(db, dialect)
+
+	default:
+		return nil, fmt.Errorf("newStore: unknown store kind %q", storeKind)
+	}
+}
+
+// loadPeriodics rehydrates the in-process periodics map from store at
+// startup. Any definition whose NextFireAt has already elapsed (the process
+// was down through one or more scheduled fires) gets it recomputed from the
+// current wall clock rather than bursting through the missed occurrences,
+// so the schedule itself - not just the tick-to-tick computation - is
+// crash-safe.
+func loadPeriodics() error {
+	loaded, err := store.ListPeriodics()
+	if err != nil {
+		return fmt.Errorf("loadPeriodics: %w", err)
+	}
+
+	now := time.Now()
+	for _, p := range loaded {
+		if !p.Paused && p.NextFireAt != 0 && p.NextFireAt <= now.Unix() {
+			next, ok := nextCronFireAt(p.Cron, now)
+			if ok {
+				p.NextFireAt = next
+			} else {
+				p.NextFireAt = 0
+			}
+			if err := store.SavePeriodic(p); err != nil {
+				log.Println("loadPeriodics: save:", p.ID, err)
+			}
+		}
+		periodics[p.ID] = p
+	}
+	return nil
+}
+
+// loadBatches rehydrates the in-process batches map from store at startup,
+// so batch bookkeeping for jobs still in flight across a restart survives
+// the same way periodic job definitions do.
+func loadBatches() error {
+	loaded, err := store.ListBatches()
+	if err != nil {
+		return fmt.Errorf("loadBatches: %w", err)
+	}
+	for _, b := range loaded {
+		batches[b.ID] = b
+	}
+	return nil
+}
+
 func main() {
+	shutdownGracePeriod := flag.Duration("shutdown-grace-period", 30*time.Second, "how long to wait for leased jobs to be acked before forcing shutdown")
+	storeKind := flag.String("store", "memory", "job store backend: memory or sql")
+	databaseURL := flag.String("database-url", "", "DSN for --store=sql (or set DATABASE_URL)")
+	flag.Parse()
+
+	s, err := newStore(*storeKind, *databaseURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store = s
+
+	if err := loadPeriodics(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadBatches(); err != nil {
+		log.Fatal(err)
+	}
+
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintln(w, "ok")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"live":true,"ready":%t}`, !shuttingDown.Load())
 	})
 
 	http.HandleFunc("/enqueue", func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
@@ -113,194 +778,682 @@ func main() {
 			return
 		}
 
-		var id string = uuid.NewString()
+		metaMu.Lock()
+		defer metaMu.Unlock()
 
-		var job *Job = &Job{
-			ID:       id,
-			Payload:  req.Payload,
-			State:    StateQueued,
-			MaxTries: 3,
+		if req.BatchID != "" {
+			if _, ok := batches[req.BatchID]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+
+		if req.JobType != "" {
+			if _, ok := jobTypes[req.JobType]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
 		}
 
-		jobsMu.Lock()
-		defer jobsMu.Unlock()
-		jobs[id] = job
+		job, err := enqueueLocked("", req.Payload, req.BatchID, req.JobType, req.Queue, req.PartitionKey)
+		if err != nil {
+			log.Println("/enqueue:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, `{"job_id":"%s"}`, id)
+		fmt.Fprintf(w, `{"job_id":"%s"}`, job.ID)
 	})
 
-	http.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
+	http.HandleFunc("/job-types", func(w http.ResponseWriter, r *http.Request) {
+		metaMu.Lock()
+		defer metaMu.Unlock()
+
+		switch r.Method {
+		case http.MethodPost:
+			var jt JobType
+			err := json.NewDecoder(r.Body).Decode(&jt)
+			if err != nil || jt.Name == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if jt.MaxTries <= 0 {
+				jt.MaxTries = 3
+			}
+			if jt.Queue == "" {
+				jt.Queue = defaultQueueName
+			}
+			getOrCreateQueueLocked(jt.Queue)
+			jobTypes[jt.Name] = &jt
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(jt)
+
+		case http.MethodGet:
+			list := make([]*JobType, 0, len(jobTypes))
+			for _, jt := range jobTypes {
+				list = append(list, jt)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/queues", func(w http.ResponseWriter, r *http.Request) {
+		metaMu.Lock()
+		defer metaMu.Unlock()
+
+		switch r.Method {
+		case http.MethodPost:
+			var req QueueRequest
+			err := json.NewDecoder(r.Body).Decode(&req)
+			if err != nil || req.Name == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			q := getOrCreateQueueLocked(req.Name)
+			q.Priority = req.Priority
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(q)
+
+		case http.MethodGet:
+			list := make([]*Queue, 0, len(queues))
+			for _, q := range queues {
+				list = append(list, q)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(list)
+
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/workers/register", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		jobsMu.Lock()
-		defer jobsMu.Unlock()
+		var req struct {
+			WorkerID string `json:"worker_id"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.WorkerID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		metaMu.Lock()
+		defer metaMu.Unlock()
+
+		worker, ok := workers[req.WorkerID]
+		if !ok {
+			worker = &Worker{
+				ID:           req.WorkerID,
+				RegisteredAt: time.Now().Unix(),
+			}
+			workers[req.WorkerID] = worker
+			reassignWorkerIndexesLocked()
+			expirePartitionedLeasesLocked()
+		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(jobs)
+		fmt.Fprintf(w, `{"worker_id":"%s","index":%d,"worker_count":%d}`, worker.ID, worker.Index, len(workers))
 	})
 
-	http.HandleFunc("/poll", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/workers/heartbeat", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req PollRequest
+		var req struct {
+			WorkerID    string  `json:"worker_id"`
+			MaxJobs     int     `json:"max_jobs,omitempty"`
+			CurrentJobs int     `json:"current_jobs,omitempty"`
+			CPUPercent  float64 `json:"cpu_pct,omitempty"`
+			MemPercent  float64 `json:"mem_pct,omitempty"`
+		}
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil || req.WorkerID == "" {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		jobsMu.Lock()
-		defer jobsMu.Unlock()
+		metaMu.Lock()
+		defer metaMu.Unlock()
 
-		now := time.Now().Unix()
+		worker, ok := workers[req.WorkerID]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		worker.LastHeartbeatAt = time.Now().Unix()
+		worker.MaxJobs = req.MaxJobs
+		worker.CurrentJobs = req.CurrentJobs
+		worker.CPUPercent = req.CPUPercent
+		worker.MemPercent = req.MemPercent
 
-		for _, job := range jobs {
-			if job.State == StateQueued && (job.NextAvailableAt == 0 || job.NextAvailableAt <= now) {
-				job.State = StateLeased
-				job.LeaseOwner = req.WorkerID
-				job.LeaseExpiresAt = now + 30
+		w.WriteHeader(http.StatusOK)
+	})
+
+	http.HandleFunc("/periodic", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req PeriodicRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		metaMu.Lock()
+		defer metaMu.Unlock()
 
-				w.Header().Set("Content-Type", "application/json")
-				json.NewEncoder(w).Encode(job)
+		if req.JobType != "" {
+			if _, ok := jobTypes[req.JobType]; !ok {
+				w.WriteHeader(http.StatusNotFound)
 				return
 			}
 		}
 
-		//when no job available
-		w.WriteHeader(http.StatusNoContent)
+		next, ok := nextCronFireAt(req.Cron, time.Now())
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		id := uuid.NewString()
+		p := &PeriodicJob{
+			ID:         id,
+			Cron:       req.Cron,
+			Payload:    req.Payload,
+			JobType:    req.JobType,
+			MaxTries:   req.MaxTries,
+			NextFireAt: next,
+		}
+		if err := store.SavePeriodic(p); err != nil {
+			log.Println("/periodic: save:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		periodics[id] = p
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"periodic_id":"%s"}`, id)
 	})
 
-	http.HandleFunc("/ack", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/periodic/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/periodic/")
+		parts := strings.SplitN(path, "/", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		id, action := parts[0], parts[1]
+
+		metaMu.Lock()
+		defer metaMu.Unlock()
+
+		p, ok := periodics[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch action {
+		case "executions":
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			list, err := store.List()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			prefix := id + ":"
+			executions := make([]*Job, 0)
+			for _, job := range list {
+				if strings.HasPrefix(job.ID, prefix) {
+					executions = append(executions, job)
+				}
+			}
+			sort.Slice(executions, func(i, j int) bool { return executions[i].ID < executions[j].ID })
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(executions)
+
+		case "pause":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			p.Paused = true
+			if err := store.SavePeriodic(p); err != nil {
+				log.Println("/periodic/pause: save:", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(p)
+
+		case "resume":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			p.Paused = false
+			if err := store.SavePeriodic(p); err != nil {
+				log.Println("/periodic/resume: save:", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(p)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	http.HandleFunc("/batches", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req AckRequest
+		var req BatchRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil || req.WorkerID == "" || req.JobID == "" {
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		jobsMu.Lock()
-		defer jobsMu.Unlock()
+		metaMu.Lock()
+		defer metaMu.Unlock()
+
+		if req.ParentBatchID != "" {
+			if _, ok := batches[req.ParentBatchID]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+
+		id := uuid.NewString()
+		b := &Batch{
+			ID:            id,
+			ParentBatchID: req.ParentBatchID,
+			Success:       req.Success,
+			Complete:      req.Complete,
+		}
+		batches[id] = b
+		saveBatchLocked(b)
+
+		if req.ParentBatchID != "" {
+			parent := batches[req.ParentBatchID]
+			parent.Pending++
+			saveBatchLocked(parent)
+		}
 
-		job, ok := jobs[req.JobID]
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"batch_id":"%s"}`, id)
+	})
+
+	http.HandleFunc("/batches/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/batches/")
+		if id == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		metaMu.Lock()
+		defer metaMu.Unlock()
+
+		b, ok := batches[id]
 		if !ok {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
 
-		if job.State == StateDone {
-			w.WriteHeader(http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b)
+	})
+
+	http.HandleFunc("/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		if job.State != StateLeased || job.LeaseOwner != req.WorkerID {
-			w.WriteHeader(http.StatusConflict)
+		list, err := store.List()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		if job.LeaseExpiresAt <= time.Now().Unix() {
-			w.WriteHeader(http.StatusConflict)
+		result := make(map[string]*Job, len(list))
+		for _, job := range list {
+			result[job.ID] = job
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	http.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		list, err := store.List()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		//mark done
-		job.State = StateDone
-		job.LeaseOwner = ""
-		job.LeaseExpiresAt = 0
+		now := time.Now().Unix()
+		counts := make(map[JobState]int)
+		var oldestEnqueuedAt int64
+		for _, job := range list {
+			counts[job.State]++
+			if job.State == StateQueued && (oldestEnqueuedAt == 0 || job.EnqueuedAt < oldestEnqueuedAt) {
+				oldestEnqueuedAt = job.EnqueuedAt
+			}
+		}
 
-		w.WriteHeader(http.StatusOK)
+		resp := StatusResponse{
+			JobCounts:       counts,
+			DeadLetterCount: counts[StateDead],
+			QueueDepth:      counts[StateQueued],
+		}
+		if oldestEnqueuedAt > 0 {
+			resp.OldestQueuedAgeSeconds = now - oldestEnqueuedAt
+		}
 
+		metaMu.Lock()
+		pruneStaleWorkersLocked(now)
+		resp.Workers = make([]*Worker, 0, len(workers))
+		for _, worker := range workers {
+			// Copy the value out while still holding metaMu: /workers/heartbeat
+			// mutates these fields under the same lock, and the sort+encode
+			// below happen after we let go of it.
+			snapshot := *worker
+			resp.Workers = append(resp.Workers, &snapshot)
+		}
+		metaMu.Unlock()
+
+		sort.Slice(resp.Workers, func(i, j int) bool { return resp.Workers[i].Index < resp.Workers[j].Index })
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
 	})
 
-	http.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/poll", func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
 		if r.Method != http.MethodPost {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		var req FailRequest
+		var req PollRequest
 		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil || req.WorkerID == "" || req.JobID == "" {
+		if err != nil || req.WorkerID == "" {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		jobsMu.Lock()
-		defer jobsMu.Unlock()
+		metaMu.Lock()
+		defer metaMu.Unlock()
 
-		job, ok := jobs[req.JobID]
-		if !ok {
-			w.WriteHeader(http.StatusNotFound)
+		if req.WorkerCount > 0 {
+			if req.WorkerCount != len(workers) || !workerIndexRegisteredLocked(req.WorkerIndex) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+
+		now := time.Now().Unix()
+
+		var candidates []*Queue
+		if len(req.Queues) > 0 {
+			for _, name := range req.Queues {
+				if q, ok := queues[name]; ok {
+					candidates = append(candidates, q)
+				}
+			}
+		} else {
+			for _, q := range queues {
+				candidates = append(candidates, q)
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].Priority != candidates[j].Priority {
+				return candidates[i].Priority > candidates[j].Priority
+			}
+			return candidates[i].Name < candidates[j].Name
+		})
+
+		queueNames := make([]string, len(candidates))
+		for i, q := range candidates {
+			queueNames[i] = q.Name
+		}
+
+		jtConcurrency := make(map[string]int, len(jobTypes))
+		for name, jt := range jobTypes {
+			if jt.Concurrency > 0 {
+				jtConcurrency[name] = jt.Concurrency
+			}
+		}
+
+		criteria := LeaseCriteria{
+			WorkerID:           req.WorkerID,
+			Queues:             queueNames,
+			Now:                now,
+			JobTypeConcurrency: jtConcurrency,
+			LeaseSeconds:       leaseSecondsForJob,
+		}
+		if req.WorkerCount > 0 {
+			criteria.PartitionOK = func(partitionKey string) bool {
+				return int(workerForPartition(partitionKey, req.WorkerCount)) == req.WorkerIndex
+			}
+		} else {
+			criteria.PartitionOK = func(partitionKey string) bool { return false }
+		}
+
+		job, err := store.LeaseNext(criteria)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		if job.State == StateDone {
-			w.WriteHeader(http.StatusOK)
+		if depth, oldestAgeSeconds, err := queueDepthAndOldestAge(now); err == nil {
+			w.Header().Set("x-queue-depth", strconv.Itoa(depth))
+			w.Header().Set("x-queue-oldest-age-seconds", strconv.FormatInt(oldestAgeSeconds, 10))
+		}
+
+		if job == nil {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
-		// Must be leased to this worker
-		if job.State != StateLeased || job.LeaseOwner != req.WorkerID {
-			w.WriteHeader(http.StatusConflict)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	})
+
+	http.HandleFunc("/ack", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		// Must not be expired
-		now := time.Now().Unix()
-		if job.LeaseExpiresAt <= now {
-			w.WriteHeader(http.StatusConflict)
+		var req AckRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.WorkerID == "" || req.JobID == "" {
+			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		// Record failure
-		job.Attempts++
+		job, result, err := store.Ack(req.JobID, req.WorkerID, time.Now().Unix())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 
-		// Too many tries => DEAD (DLQ behavior)
-		if job.Attempts >= job.MaxTries {
-			job.State = StateDead
-			job.LeaseOwner = ""
-			job.LeaseExpiresAt = 0
-			job.NextAvailableAt = 0
+		switch result {
+		case AckNotFound:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case AckAlreadyDone:
 			w.WriteHeader(http.StatusOK)
 			return
+		case AckConflict:
+			w.WriteHeader(http.StatusConflict)
+			return
 		}
 
-		// Retry later with backoff + full jitter
-		delay := retryDelaySeconds(job.Attempts)
-		fmt.Println("retry scheduled:", job.ID, "attempts:", job.Attempts, "delay_s:", delay)
+		metaMu.Lock()
+		defer metaMu.Unlock()
 
-		job.State = StateQueued
-		job.LeaseOwner = ""
-		job.LeaseExpiresAt = 0
-		job.NextAvailableAt = now + delay
+		if job.BatchID != "" {
+			if b, ok := batches[job.BatchID]; ok {
+				b.Pending--
+				b.Succeeded++
+				saveBatchLocked(b)
+				if b.Pending == 0 {
+					completeBatchLocked(b)
+				}
+			}
+		}
 
 		w.WriteHeader(http.StatusOK)
+	})
+
+	http.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req FailRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.WorkerID == "" || req.JobID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		job, result, err := store.Fail(req.JobID, req.WorkerID, time.Now().Unix())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
 
+		switch result {
+		case FailNotFound:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case FailAlreadyDone:
+			w.WriteHeader(http.StatusOK)
+			return
+		case FailConflict:
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		metaMu.Lock()
+		defer metaMu.Unlock()
+
+		if result == FailDead {
+			if job.BatchID != "" {
+				if b, ok := batches[job.BatchID]; ok {
+					b.Pending--
+					b.Failed++
+					saveBatchLocked(b)
+					if b.Pending == 0 {
+						completeBatchLocked(b)
+					}
+				}
+			}
+		} else {
+			fmt.Println("retry scheduled:", job.ID, "attempts:", job.Attempts, "next_available_at:", job.NextAvailableAt)
+		}
+
+		w.WriteHeader(http.StatusOK)
 	})
 
+	tickerDone := make(chan struct{})
 	go func() {
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			ids := expireLeases(time.Now().Unix())
-			if len(ids) > 0 {
-				fmt.Println("expired lease: ", ids)
+		for {
+			select {
+			case <-ticker.C:
+				now := time.Now().Unix()
+
+				ids := expireLeases(now)
+				if len(ids) > 0 {
+					fmt.Println("expired lease: ", ids)
+				}
+
+				tickPeriodics(now)
+				pruneStaleWorkers(now)
+			case <-tickerDone:
+				return
 			}
 		}
 	}()
 
-	log.Println("Listening on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	srv := &http.Server{Addr: ":8080"}
+
+	go func() {
+		log.Println("Listening on port 8080")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	log.Println("shutdown signal received, draining leased jobs")
+	shuttingDown.Store(true)
+
+	deadline := time.Now().Add(*shutdownGracePeriod)
+	for time.Now().Before(deadline) && hasLeasedJobs() {
+		time.Sleep(200 * time.Millisecond)
+	}
+	if ids := requeueOrphanedLeases(); len(ids) > 0 {
+		fmt.Println("requeued orphaned leases at shutdown: ", ids)
+	}
+
+	close(tickerDone)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("error during server shutdown:", err)
+	}
 }
@@ -0,0 +1,79 @@
+package main
+
+// LeaseCriteria narrows which job LeaseNext should pick: which queues are
+// eligible (already ordered by priority, highest first) and the scheduling
+// hooks that used to live inline in the /poll handler.
+type LeaseCriteria struct {
+	WorkerID string
+	Queues   []string
+	Now      int64
+
+	// JobTypeConcurrency caps how many jobs of a given job_type may be
+	// StateLeased at once, keyed by JobType.Name; entries with no limit are
+	// omitted. Counted against the Store's own job records (not an
+	// in-process counter) so the limit holds across every replica sharing
+	// one Store, not just within a single process.
+	JobTypeConcurrency map[string]int
+
+	// PartitionOK reports whether this poller is allowed to lease a job
+	// carrying this partition key. Nil means partition routing is off.
+	PartitionOK func(partitionKey string) bool
+
+	// LeaseSeconds returns how long to lease a job for. Nil defaults to 30s.
+	LeaseSeconds func(job *Job) int64
+}
+
+type AckResult int
+
+const (
+	AckOK AckResult = iota
+	AckAlreadyDone
+	AckConflict
+	AckNotFound
+)
+
+type FailResult int
+
+const (
+	FailRetried FailResult = iota
+	FailDead
+	FailAlreadyDone
+	FailConflict
+	FailNotFound
+)
+
+// Store is the persistence boundary for job state. MemoryStore keeps
+// everything in a process-local map and loses it all on crash; SQLStore
+// persists to SQLite/Postgres so the queue survives a restart.
+type Store interface {
+	Enqueue(job *Job) error
+	LeaseNext(c LeaseCriteria) (*Job, error)
+	Ack(jobID, workerID string, now int64) (*Job, AckResult, error)
+	Fail(jobID, workerID string, now int64) (*Job, FailResult, error)
+	ExpireLeases(now int64) ([]*Job, error)
+	Get(jobID string) (*Job, bool, error)
+	List() ([]*Job, error)
+
+	// Requeue forces a leased job back to QUEUED regardless of whether its
+	// lease has actually expired. Used for shutdown draining and for
+	// rehashing partition-keyed leases when worker membership changes.
+	Requeue(jobID string) (*Job, error)
+
+	// SavePeriodic upserts a periodic job definition by ID, so cron
+	// schedules survive a process restart the same way job state does.
+	SavePeriodic(p *PeriodicJob) error
+
+	// ListPeriodics returns every persisted periodic job definition, used
+	// to rehydrate the in-process periodics map on startup.
+	ListPeriodics() ([]*PeriodicJob, error)
+
+	// SaveBatch upserts a batch's counters and callback payloads by ID, so
+	// batch bookkeeping survives a process restart the same way job state
+	// does - otherwise a batch whose jobs are still in flight across a
+	// restart never fires its callbacks.
+	SaveBatch(b *Batch) error
+
+	// ListBatches returns every persisted batch, used to rehydrate the
+	// in-process batches map on startup.
+	ListBatches() ([]*Batch, error)
+}
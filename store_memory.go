@@ -0,0 +1,251 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is the original in-process job store: a map guarded by a
+// mutex, with a ready-list ("backlog") per queue so LeaseNext doesn't have
+// to scan every job. Nothing here survives a process restart.
+type MemoryStore struct {
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	backlog   map[string][]string
+	periodics map[string]*PeriodicJob
+	batches   map[string]*Batch
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		jobs:      make(map[string]*Job),
+		backlog:   make(map[string][]string),
+		periodics: make(map[string]*PeriodicJob),
+		batches:   make(map[string]*Batch),
+	}
+}
+
+func (s *MemoryStore) Enqueue(job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.ID == "" {
+		job.ID = uuid.NewString()
+	}
+	s.jobs[job.ID] = job
+	s.backlog[job.Queue] = append(s.backlog[job.Queue], job.ID)
+	return nil
+}
+
+// concurrencyOKLocked reports whether leasing another job of jobType would
+// stay within limits[jobType], counting currently StateLeased jobs directly
+// from s.jobs rather than a separately-tracked counter. Callers must hold
+// s.mu.
+func (s *MemoryStore) concurrencyOKLocked(jobType string, limits map[string]int) bool {
+	limit, ok := limits[jobType]
+	if !ok || limit <= 0 {
+		return true
+	}
+	active := 0
+	for _, job := range s.jobs {
+		if job.JobType == jobType && job.State == StateLeased {
+			active++
+		}
+	}
+	return active < limit
+}
+
+func (s *MemoryStore) LeaseNext(c LeaseCriteria) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, queueName := range c.Queues {
+		list := s.backlog[queueName]
+		for i, id := range list {
+			job := s.jobs[id]
+			if job == nil || job.State != StateQueued {
+				continue
+			}
+			if job.NextAvailableAt != 0 && job.NextAvailableAt > c.Now {
+				continue
+			}
+			if job.JobType != "" && !s.concurrencyOKLocked(job.JobType, c.JobTypeConcurrency) {
+				continue
+			}
+			if job.PartitionKey != "" && c.PartitionOK != nil && !c.PartitionOK(job.PartitionKey) {
+				continue
+			}
+
+			s.backlog[queueName] = append(list[:i:i], list[i+1:]...)
+
+			job.State = StateLeased
+			job.LeaseOwner = c.WorkerID
+			leaseSeconds := int64(30)
+			if c.LeaseSeconds != nil {
+				leaseSeconds = c.LeaseSeconds(job)
+			}
+			job.LeaseExpiresAt = c.Now + leaseSeconds
+
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Ack(jobID, workerID string, now int64) (*Job, AckResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, AckNotFound, nil
+	}
+	if job.State == StateDone {
+		return job, AckAlreadyDone, nil
+	}
+	if job.State != StateLeased || job.LeaseOwner != workerID {
+		return job, AckConflict, nil
+	}
+	if job.LeaseExpiresAt <= now {
+		return job, AckConflict, nil
+	}
+
+	job.State = StateDone
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = 0
+
+	return job, AckOK, nil
+}
+
+func (s *MemoryStore) Fail(jobID, workerID string, now int64) (*Job, FailResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, FailNotFound, nil
+	}
+	if job.State == StateDone {
+		return job, FailAlreadyDone, nil
+	}
+	if job.State != StateLeased || job.LeaseOwner != workerID {
+		return job, FailConflict, nil
+	}
+	if job.LeaseExpiresAt <= now {
+		return job, FailConflict, nil
+	}
+
+	job.Attempts++
+
+	if job.Attempts >= job.MaxTries {
+		job.State = StateDead
+		job.LeaseOwner = ""
+		job.LeaseExpiresAt = 0
+		job.NextAvailableAt = 0
+		return job, FailDead, nil
+	}
+
+	delay := retryDelaySeconds(job.Attempts)
+	job.State = StateQueued
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = 0
+	job.NextAvailableAt = now + delay
+	s.backlog[job.Queue] = append(s.backlog[job.Queue], job.ID)
+
+	return job, FailRetried, nil
+}
+
+func (s *MemoryStore) ExpireLeases(now int64) ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []*Job
+	for _, job := range s.jobs {
+		if job.State == StateLeased && job.LeaseExpiresAt > 0 && job.LeaseExpiresAt <= now {
+			job.State = StateQueued
+			job.LeaseOwner = ""
+			job.LeaseExpiresAt = 0
+			job.NextAvailableAt = 0
+			s.backlog[job.Queue] = append(s.backlog[job.Queue], job.ID)
+			expired = append(expired, job)
+		}
+	}
+	return expired, nil
+}
+
+func (s *MemoryStore) Get(jobID string) (*Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobID]
+	return job, ok, nil
+}
+
+func (s *MemoryStore) List() ([]*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		list = append(list, job)
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) Requeue(jobID string) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return nil, nil
+	}
+	job.State = StateQueued
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = 0
+	s.backlog[job.Queue] = append(s.backlog[job.Queue], job.ID)
+	return job, nil
+}
+
+func (s *MemoryStore) SavePeriodic(p *PeriodicJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *p
+	s.periodics[p.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) ListPeriodics() ([]*PeriodicJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*PeriodicJob, 0, len(s.periodics))
+	for _, p := range s.periodics {
+		cp := *p
+		list = append(list, &cp)
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) SaveBatch(b *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := *b
+	s.batches[b.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) ListBatches() ([]*Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Batch, 0, len(s.batches))
+	for _, b := range s.batches {
+		cp := *b
+		list = append(list, &cp)
+	}
+	return list, nil
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SQLStore persists job state to SQLite or Postgres via database/sql. The
+// schema is a single "jobs" table; the ready-list that MemoryStore keeps
+// as an in-process backlog is just a WHERE state='queued' scan here,
+// since an index does that job for us once the table is real.
+//
+// Postgres gets LeaseNext implemented with SELECT ... FOR UPDATE SKIP
+// LOCKED so two servers polling the same row never both win the lease.
+// SQLite has no such clause (and only one writer at a time anyway), so
+// its LeaseNext just does the claim inside a plain transaction.
+type SQLStore struct {
+	db      *sql.DB
+	dialect string // "postgres" or "sqlite"
+}
+
+// NewSQLStore opens db and wraps it as a Store. dialect must be "postgres"
+// or "sqlite"; callers pick it from the DSN scheme (see newStore in main.go).
+func NewSQLStore(db *sql.DB, dialect string) (*SQLStore, error) {
+	if dialect != "postgres" && dialect != "sqlite" {
+		return nil, fmt.Errorf("sqlstore: unsupported dialect %q", dialect)
+	}
+	s := &SQLStore{db: db, dialect: dialect}
+	if err := s.migrate(); err != nil {
+		return nil, fmt.Errorf("sqlstore: migrate: %w", err)
+	}
+	return s, nil
+}
+
+// seqColumn is a monotonically increasing column LeaseNext orders by so
+// jobs are leased oldest-first. SQLite's implicit "rowid" already does
+// this for free; Postgres has no such pseudo-column, so its table gets a
+// real bigserial instead.
+func (s *SQLStore) seqColumn() string {
+	if s.dialect == "postgres" {
+		return "seq"
+	}
+	return "rowid"
+}
+
+func (s *SQLStore) migrate() error {
+	schema := `
+		CREATE TABLE IF NOT EXISTS jobs (
+			id                text PRIMARY KEY,
+			payload           text NOT NULL,
+			state             text NOT NULL,
+			lease_owner       text NOT NULL DEFAULT '',
+			lease_expires_at  bigint NOT NULL DEFAULT 0,
+			attempts          integer NOT NULL DEFAULT 0,
+			max_tries         integer NOT NULL DEFAULT 0,
+			next_available_at bigint NOT NULL DEFAULT 0,
+			batch_id          text NOT NULL DEFAULT '',
+			job_type          text NOT NULL DEFAULT '',
+			queue             text NOT NULL DEFAULT '',
+			partition_key     text NOT NULL DEFAULT ''
+		)`
+	if s.dialect == "postgres" {
+		schema = `
+		CREATE TABLE IF NOT EXISTS jobs (
+			seq               bigserial,
+			id                text PRIMARY KEY,
+			payload           text NOT NULL,
+			state             text NOT NULL,
+			lease_owner       text NOT NULL DEFAULT '',
+			lease_expires_at  bigint NOT NULL DEFAULT 0,
+			attempts          integer NOT NULL DEFAULT 0,
+			max_tries         integer NOT NULL DEFAULT 0,
+			next_available_at bigint NOT NULL DEFAULT 0,
+			batch_id          text NOT NULL DEFAULT '',
+			job_type          text NOT NULL DEFAULT '',
+			queue             text NOT NULL DEFAULT '',
+			partition_key     text NOT NULL DEFAULT ''
+		)`
+	}
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS periodic_jobs (
+			id           text PRIMARY KEY,
+			cron         text NOT NULL,
+			payload      text NOT NULL,
+			job_type     text NOT NULL DEFAULT '',
+			max_tries    integer NOT NULL DEFAULT 0,
+			next_fire_at bigint NOT NULL DEFAULT 0,
+			paused       boolean NOT NULL DEFAULT false
+		)`); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS batches (
+			id               text PRIMARY KEY,
+			parent_batch_id  text NOT NULL DEFAULT '',
+			success_payload  text NOT NULL DEFAULT '',
+			has_success      boolean NOT NULL DEFAULT false,
+			complete_payload text NOT NULL DEFAULT '',
+			has_complete     boolean NOT NULL DEFAULT false,
+			pending          integer NOT NULL DEFAULT 0,
+			succeeded        integer NOT NULL DEFAULT 0,
+			failed           integer NOT NULL DEFAULT 0
+		)`)
+	return err
+}
+
+// placeholder returns the n-th bind placeholder for this dialect ($1, $2,
+// ... for Postgres; ? for SQLite).
+func (s *SQLStore) placeholder(n int) string {
+	if s.dialect == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+func scanJob(row interface {
+	Scan(dest ...interface{}) error
+}) (*Job, error) {
+	job := &Job{}
+	err := row.Scan(
+		&job.ID, &job.Payload, &job.State, &job.LeaseOwner, &job.LeaseExpiresAt,
+		&job.Attempts, &job.MaxTries, &job.NextAvailableAt, &job.BatchID,
+		&job.JobType, &job.Queue, &job.PartitionKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+const jobColumns = `id, payload, state, lease_owner, lease_expires_at, attempts, max_tries, next_available_at, batch_id, job_type, queue, partition_key`
+
+func (s *SQLStore) Enqueue(job *Job) error {
+	q := fmt.Sprintf(`INSERT INTO jobs (%s) VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s)`,
+		jobColumns,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+		s.placeholder(5), s.placeholder(6), s.placeholder(7), s.placeholder(8),
+		s.placeholder(9), s.placeholder(10), s.placeholder(11), s.placeholder(12))
+	_, err := s.db.Exec(q,
+		job.ID, job.Payload, job.State, job.LeaseOwner, job.LeaseExpiresAt,
+		job.Attempts, job.MaxTries, job.NextAvailableAt, job.BatchID,
+		job.JobType, job.Queue, job.PartitionKey)
+	return err
+}
+
+// nextCandidateInQueue walks queueName's ready backlog one row at a time
+// (ORDER BY seq/rowid LIMIT 1 OFFSET n), advancing past rows that fail the
+// Go-side concurrency/partition checks, until it finds a leasable job or
+// runs out of rows. Fetching one row per query - instead of the whole
+// backlog up front - means a poll only ever row-locks the candidates it
+// actually inspects, not every ready job in the queue. Callers must be
+// inside tx; on Postgres, rows already locked by other transactions are
+// skipped automatically by FOR UPDATE SKIP LOCKED.
+func (s *SQLStore) nextCandidateInQueue(tx *sql.Tx, queueName string, c LeaseCriteria) (*Job, error) {
+	for offset := 0; ; offset++ {
+		selectQ := fmt.Sprintf(
+			`SELECT %s FROM jobs WHERE queue = %s AND state = %s AND next_available_at <= %s ORDER BY %s LIMIT 1 OFFSET %d`,
+			jobColumns, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.seqColumn(), offset)
+		if s.dialect == "postgres" {
+			selectQ += " FOR UPDATE SKIP LOCKED"
+		}
+
+		job, err := scanJob(tx.QueryRow(selectQ, queueName, StateQueued, c.Now))
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if job.JobType != "" {
+			ok, err := s.concurrencyOK(tx, job.JobType, c.JobTypeConcurrency)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if job.PartitionKey != "" && c.PartitionOK != nil && !c.PartitionOK(job.PartitionKey) {
+			continue
+		}
+		return job, nil
+	}
+}
+
+// concurrencyOK reports whether leasing another job of jobType would stay
+// within limits[jobType], counting currently StateLeased rows for that type
+// from the jobs table itself (within tx) rather than a process-local
+// counter - so the limit is enforced against every replica sharing this
+// database, not just the one handling this poll.
+func (s *SQLStore) concurrencyOK(tx *sql.Tx, jobType string, limits map[string]int) (bool, error) {
+	limit, ok := limits[jobType]
+	if !ok || limit <= 0 {
+		return true, nil
+	}
+
+	q := fmt.Sprintf(`SELECT COUNT(*) FROM jobs WHERE job_type = %s AND state = %s`,
+		s.placeholder(1), s.placeholder(2))
+	var active int
+	if err := tx.QueryRow(q, jobType, StateLeased).Scan(&active); err != nil {
+		return false, err
+	}
+	return active < limit, nil
+}
+
+func (s *SQLStore) LeaseNext(c LeaseCriteria) (*Job, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, queueName := range c.Queues {
+		candidate, err := s.nextCandidateInQueue(tx, queueName, c)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+
+		leaseSeconds := int64(30)
+		if c.LeaseSeconds != nil {
+			leaseSeconds = c.LeaseSeconds(candidate)
+		}
+		candidate.State = StateLeased
+		candidate.LeaseOwner = c.WorkerID
+		candidate.LeaseExpiresAt = c.Now + leaseSeconds
+
+		updateQ := fmt.Sprintf(`UPDATE jobs SET state = %s, lease_owner = %s, lease_expires_at = %s WHERE id = %s`,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4))
+		if _, err := tx.Exec(updateQ, candidate.State, candidate.LeaseOwner, candidate.LeaseExpiresAt, candidate.ID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return candidate, nil
+	}
+
+	return nil, tx.Commit()
+}
+
+func (s *SQLStore) getForUpdate(tx *sql.Tx, jobID string) (*Job, error) {
+	q := fmt.Sprintf(`SELECT %s FROM jobs WHERE id = %s`, jobColumns, s.placeholder(1))
+	if s.dialect == "postgres" {
+		q += " FOR UPDATE"
+	}
+	job, err := scanJob(tx.QueryRow(q, jobID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (s *SQLStore) Ack(jobID, workerID string, now int64) (*Job, AckResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, AckNotFound, err
+	}
+	defer tx.Rollback()
+
+	job, err := s.getForUpdate(tx, jobID)
+	if err != nil {
+		return nil, AckNotFound, err
+	}
+	if job == nil {
+		return nil, AckNotFound, nil
+	}
+	if job.State == StateDone {
+		return job, AckAlreadyDone, tx.Commit()
+	}
+	if job.State != StateLeased || job.LeaseOwner != workerID || job.LeaseExpiresAt <= now {
+		return job, AckConflict, tx.Commit()
+	}
+
+	job.State = StateDone
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = 0
+
+	q := fmt.Sprintf(`UPDATE jobs SET state = %s, lease_owner = %s, lease_expires_at = %s WHERE id = %s`,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4))
+	if _, err := tx.Exec(q, job.State, job.LeaseOwner, job.LeaseExpiresAt, job.ID); err != nil {
+		return nil, AckNotFound, err
+	}
+	return job, AckOK, tx.Commit()
+}
+
+func (s *SQLStore) Fail(jobID, workerID string, now int64) (*Job, FailResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, FailNotFound, err
+	}
+	defer tx.Rollback()
+
+	job, err := s.getForUpdate(tx, jobID)
+	if err != nil {
+		return nil, FailNotFound, err
+	}
+	if job == nil {
+		return nil, FailNotFound, nil
+	}
+	if job.State == StateDone {
+		return job, FailAlreadyDone, tx.Commit()
+	}
+	if job.State != StateLeased || job.LeaseOwner != workerID || job.LeaseExpiresAt <= now {
+		return job, FailConflict, tx.Commit()
+	}
+
+	job.Attempts++
+	result := FailRetried
+	if job.Attempts >= job.MaxTries {
+		job.State = StateDead
+		job.LeaseOwner = ""
+		job.LeaseExpiresAt = 0
+		job.NextAvailableAt = 0
+		result = FailDead
+	} else {
+		job.State = StateQueued
+		job.LeaseOwner = ""
+		job.LeaseExpiresAt = 0
+		job.NextAvailableAt = now + retryDelaySeconds(job.Attempts)
+	}
+
+	q := fmt.Sprintf(`UPDATE jobs SET state = %s, lease_owner = %s, lease_expires_at = %s, attempts = %s, next_available_at = %s WHERE id = %s`,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5), s.placeholder(6))
+	if _, err := tx.Exec(q, job.State, job.LeaseOwner, job.LeaseExpiresAt, job.Attempts, job.NextAvailableAt, job.ID); err != nil {
+		return nil, FailNotFound, err
+	}
+	return job, result, tx.Commit()
+}
+
+func (s *SQLStore) ExpireLeases(now int64) ([]*Job, error) {
+	q := fmt.Sprintf(`SELECT %s FROM jobs WHERE state = %s AND lease_expires_at > 0 AND lease_expires_at <= %s`,
+		jobColumns, s.placeholder(1), s.placeholder(2))
+	rows, err := s.db.Query(q, StateLeased, now)
+	if err != nil {
+		return nil, err
+	}
+	var expired []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, job)
+	}
+	rows.Close()
+
+	for _, job := range expired {
+		job.State = StateQueued
+		job.LeaseOwner = ""
+		job.LeaseExpiresAt = 0
+		job.NextAvailableAt = 0
+		updateQ := fmt.Sprintf(`UPDATE jobs SET state = %s, lease_owner = %s, lease_expires_at = %s, next_available_at = %s WHERE id = %s`,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4), s.placeholder(5))
+		if _, err := s.db.Exec(updateQ, job.State, job.LeaseOwner, job.LeaseExpiresAt, job.NextAvailableAt, job.ID); err != nil {
+			return nil, err
+		}
+	}
+	return expired, nil
+}
+
+func (s *SQLStore) Get(jobID string) (*Job, bool, error) {
+	q := fmt.Sprintf(`SELECT %s FROM jobs WHERE id = %s`, jobColumns, s.placeholder(1))
+	job, err := scanJob(s.db.QueryRow(q, jobID))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return job, true, nil
+}
+
+func (s *SQLStore) List() ([]*Job, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT %s FROM jobs`, jobColumns))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, job)
+	}
+	return list, nil
+}
+
+func (s *SQLStore) Requeue(jobID string) (*Job, error) {
+	job, ok, err := s.Get(jobID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	job.State = StateQueued
+	job.LeaseOwner = ""
+	job.LeaseExpiresAt = 0
+
+	q := fmt.Sprintf(`UPDATE jobs SET state = %s, lease_owner = %s, lease_expires_at = %s WHERE id = %s`,
+		s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4))
+	if _, err := s.db.Exec(q, job.State, job.LeaseOwner, job.LeaseExpiresAt, job.ID); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+const periodicColumns = `id, cron, payload, job_type, max_tries, next_fire_at, paused`
+
+func scanPeriodic(row interface {
+	Scan(dest ...interface{}) error
+}) (*PeriodicJob, error) {
+	p := &PeriodicJob{}
+	err := row.Scan(&p.ID, &p.Cron, &p.Payload, &p.JobType, &p.MaxTries, &p.NextFireAt, &p.Paused)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *SQLStore) SavePeriodic(p *PeriodicJob) error {
+	var q string
+	if s.dialect == "postgres" {
+		q = fmt.Sprintf(`INSERT INTO periodic_jobs (%s) VALUES (%s,%s,%s,%s,%s,%s,%s)
+			ON CONFLICT (id) DO UPDATE SET cron = excluded.cron, payload = excluded.payload,
+				job_type = excluded.job_type, max_tries = excluded.max_tries,
+				next_fire_at = excluded.next_fire_at, paused = excluded.paused`,
+			periodicColumns,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+			s.placeholder(5), s.placeholder(6), s.placeholder(7))
+	} else {
+		q = fmt.Sprintf(`INSERT OR REPLACE INTO periodic_jobs (%s) VALUES (%s,%s,%s,%s,%s,%s,%s)`,
+			periodicColumns,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+			s.placeholder(5), s.placeholder(6), s.placeholder(7))
+	}
+	_, err := s.db.Exec(q, p.ID, p.Cron, p.Payload, p.JobType, p.MaxTries, p.NextFireAt, p.Paused)
+	return err
+}
+
+func (s *SQLStore) ListPeriodics() ([]*PeriodicJob, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT %s FROM periodic_jobs`, periodicColumns))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*PeriodicJob
+	for rows.Next() {
+		p, err := scanPeriodic(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+const batchColumns = `id, parent_batch_id, success_payload, has_success, complete_payload, has_complete, pending, succeeded, failed`
+
+func scanBatch(row interface {
+	Scan(dest ...interface{}) error
+}) (*Batch, error) {
+	b := &Batch{}
+	var successPayload, completePayload string
+	var hasSuccess, hasComplete bool
+	err := row.Scan(&b.ID, &b.ParentBatchID, &successPayload, &hasSuccess,
+		&completePayload, &hasComplete, &b.Pending, &b.Succeeded, &b.Failed)
+	if err != nil {
+		return nil, err
+	}
+	if hasSuccess {
+		b.Success = &CallbackJob{Payload: successPayload}
+	}
+	if hasComplete {
+		b.Complete = &CallbackJob{Payload: completePayload}
+	}
+	return b, nil
+}
+
+func (s *SQLStore) SaveBatch(b *Batch) error {
+	var successPayload, completePayload string
+	var hasSuccess, hasComplete bool
+	if b.Success != nil {
+		successPayload, hasSuccess = b.Success.Payload, true
+	}
+	if b.Complete != nil {
+		completePayload, hasComplete = b.Complete.Payload, true
+	}
+
+	var q string
+	if s.dialect == "postgres" {
+		q = fmt.Sprintf(`INSERT INTO batches (%s) VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s)
+			ON CONFLICT (id) DO UPDATE SET parent_batch_id = excluded.parent_batch_id,
+				success_payload = excluded.success_payload, has_success = excluded.has_success,
+				complete_payload = excluded.complete_payload, has_complete = excluded.has_complete,
+				pending = excluded.pending, succeeded = excluded.succeeded, failed = excluded.failed`,
+			batchColumns,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+			s.placeholder(5), s.placeholder(6), s.placeholder(7), s.placeholder(8), s.placeholder(9))
+	} else {
+		q = fmt.Sprintf(`INSERT OR REPLACE INTO batches (%s) VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s)`,
+			batchColumns,
+			s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4),
+			s.placeholder(5), s.placeholder(6), s.placeholder(7), s.placeholder(8), s.placeholder(9))
+	}
+	_, err := s.db.Exec(q, b.ID, b.ParentBatchID, successPayload, hasSuccess,
+		completePayload, hasComplete, b.Pending, b.Succeeded, b.Failed)
+	return err
+}
+
+func (s *SQLStore) ListBatches() ([]*Batch, error) {
+	rows, err := s.db.Query(fmt.Sprintf(`SELECT %s FROM batches`, batchColumns))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Batch
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+// dialectFromDSN infers the SQL dialect from a DATABASE_URL-style DSN, e.g.
+// "postgres://..." / "postgresql://..." -> "postgres", "sqlite://..." or a
+// bare file path -> "sqlite".
+func dialectFromDSN(dsn string) string {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return "postgres"
+	default:
+		return "sqlite"
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSQLStoreLeaseNextConcurrentNoDoubleLease(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "lease.db")
+	// _busy_timeout makes sqlite3 retry internally instead of immediately
+	// returning SQLITE_BUSY when the concurrent goroutines below collide on
+	// the single-writer lock.
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+	// sqlite3 only ever allows one writer at a time; sharing a single
+	// connection serializes the goroutines below onto it instead of each
+	// opening its own connection and fighting over the file lock.
+	db.SetMaxOpenConns(1)
+
+	sqlStore, err := NewSQLStore(db, "sqlite")
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+
+	const numJobs = 50
+	for i := 0; i < numJobs; i++ {
+		job := &Job{
+			ID:       fmt.Sprintf("job-%d", i),
+			Payload:  "payload",
+			State:    StateQueued,
+			MaxTries: 3,
+			Queue:    "default",
+		}
+		if err := sqlStore.Enqueue(job); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	const numWorkers = 10
+	leased := make(chan string, numJobs*2)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for {
+				job, err := sqlStore.LeaseNext(LeaseCriteria{
+					WorkerID: fmt.Sprintf("worker-%d", workerID),
+					Queues:   []string{"default"},
+					Now:      time.Now().Unix(),
+				})
+				if err != nil {
+					t.Errorf("LeaseNext: %v", err)
+					return
+				}
+				if job == nil {
+					return
+				}
+				leased <- job.ID
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(leased)
+
+	counts := make(map[string]int)
+	for id := range leased {
+		counts[id]++
+	}
+	for id, n := range counts {
+		if n > 1 {
+			t.Errorf("job %s leased %d times concurrently, want at most 1", id, n)
+		}
+	}
+	if len(counts) != numJobs {
+		t.Errorf("leased %d distinct jobs, want all %d", len(counts), numJobs)
+	}
+}